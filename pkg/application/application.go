@@ -0,0 +1,476 @@
+package application
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+
+	"github.com/argoproj-labs/argocd-autopilot/pkg/fs"
+	"github.com/argoproj-labs/argocd-autopilot/pkg/kube"
+	"github.com/argoproj-labs/argocd-autopilot/pkg/store"
+
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/kustomize/api/krusty"
+	kusttypes "sigs.k8s.io/kustomize/api/types"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+	"sigs.k8s.io/yaml"
+)
+
+//go:generate mockery --name Application --filename application.go
+
+const (
+	// AppTypeKustomize is a kustomize based application
+	AppTypeKustomize = "kustomize"
+
+	// AppTypeHelm is a helm chart based application
+	AppTypeHelm = "helm"
+
+	// AppTypeKsonnet is a ksonnet based application (legacy, detection only)
+	AppTypeKsonnet = "ksonnet"
+
+	// AppTypeDirectory is a plain directory of manifests
+	AppTypeDirectory = "dir"
+)
+
+// InstallationMode controls how an application's manifests end up in the overlay.
+type InstallationMode string
+
+const (
+	// InstallationModeNormal references the base directly from the overlay.
+	InstallationModeNormal InstallationMode = "normal"
+
+	// InstallationModeFlat renders the base into a single install.yaml file.
+	InstallationModeFlat InstallationMode = "flat"
+)
+
+var (
+	ErrEmptyAppSpecifier            = errors.New("empty app specifier not allowed")
+	ErrEmptyAppName                 = errors.New("app name cannot be empty, please specify application name")
+	ErrEmptyProjectName             = errors.New("project name cannot be empty")
+	ErrEmptyHelmChart               = errors.New("helm chart name cannot be empty")
+	ErrAppCollisionWithExistingBase = errors.New("an application with the same name already exists with a different source")
+	ErrAppAlreadyInstalledOnProject = errors.New("application already installed on project")
+
+	// generateManifests is a seam used by tests to avoid shelling out to kustomize.
+	generateManifests = generateManifestsImpl
+)
+
+// CreateOptions holds all the data required to create any of the supported application types.
+type CreateOptions struct {
+	AppName          string
+	AppType          string
+	AppSpecifier     string
+	InstallationMode InstallationMode
+	DestNamespace    string
+
+	// Helm chart coordinates, used when AppType is AppTypeHelm. Callers set these fields
+	// directly; this package does not parse them from command-line flags.
+	HelmChart       string
+	HelmVersion     string
+	HelmRepo        string
+	HelmValues      string
+	HelmValuesFiles []string
+	HelmSet         []string
+
+	// Functions is a chain of KRM functions to run over the rendered kustomize output before
+	// it's written to the app's base/overlay. Functions declared via the
+	// config.kubernetes.io/function annotation on files inside the base directory are appended
+	// to this list at CreateFiles time. Callers set this field directly; this package does not
+	// parse it from command-line flags.
+	Functions []FunctionSpec
+}
+
+// Config is persisted as overlays/<project>/config.json and describes how an application's
+// source should be rendered into the Argo CD Application manifest.
+type Config struct {
+	AppName           string `json:"appName"`
+	UserGivenName     string `json:"userGivenName,omitempty"`
+	DestNamespace     string `json:"destNamespace,omitempty"`
+	SrcPath           string `json:"srcPath,omitempty"`
+	SrcRepoURL        string `json:"srcRepoURL,omitempty"`
+	SrcTargetRevision string `json:"srcTargetRevision,omitempty"`
+
+	// SrcChart is set instead of SrcPath for helm-chart backed applications, so that the
+	// generated Argo CD Application uses a Helm source instead of a path-based one.
+	SrcChart string `json:"srcChart,omitempty"`
+
+	// Functions records the KRM function pipeline that was run over this app's manifests, so
+	// that a later `repo bootstrap` re-run reproduces the same output.
+	Functions []FunctionSpec `json:"functions,omitempty"`
+}
+
+// Application is the interface all supported application types implement.
+type Application interface {
+	// Name returns the user-given name of the application.
+	Name() string
+
+	// CreateFiles writes all of the application's files to repofs, for the given project.
+	CreateFiles(repofs fs.FS, projectName string) error
+
+	// Delete removes the application from the given project.
+	Delete(repofs fs.FS, projectName string) error
+}
+
+type baseApp struct {
+	opts *CreateOptions
+}
+
+func (app *baseApp) Name() string {
+	return app.opts.AppName
+}
+
+func (app *baseApp) Delete(repofs fs.FS, projectName string) error {
+	return DeleteFromProject(repofs, app.opts.AppName, projectName)
+}
+
+type kustApp struct {
+	baseApp
+
+	base      *kusttypes.Kustomization
+	overlay   *kusttypes.Kustomization
+	namespace *corev1.Namespace
+	manifests []byte
+	functions []FunctionSpec
+	config    *Config
+}
+
+// GenerateApp dispatches to the right constructor for opts.AppType.
+func GenerateApp(opts *CreateOptions, projectName, srcRepoURL, srcTargetRevision string) (Application, error) {
+	switch opts.AppType {
+	case AppTypeHelm:
+		return newHelmApp(opts, projectName, srcRepoURL, srcTargetRevision)
+	case AppTypeKustomize, "":
+		return newKustApp(opts, projectName, srcRepoURL, srcTargetRevision)
+	default:
+		return newDirApp(opts, projectName, srcRepoURL, srcTargetRevision), nil
+	}
+}
+
+func newKustApp(o *CreateOptions, projectName, srcRepoURL, srcTargetRevision string) (*kustApp, error) {
+	if o.AppSpecifier == "" {
+		return nil, ErrEmptyAppSpecifier
+	}
+
+	if o.AppName == "" {
+		return nil, ErrEmptyAppName
+	}
+
+	if projectName == "" {
+		return nil, ErrEmptyProjectName
+	}
+
+	app := &kustApp{
+		baseApp: baseApp{opts: o},
+	}
+
+	base := &kusttypes.Kustomization{
+		TypeMeta: kusttypes.TypeMeta{
+			APIVersion: kusttypes.KustomizationVersion,
+			Kind:       kusttypes.KustomizationKind,
+		},
+		Resources: []string{o.AppSpecifier},
+	}
+
+	overlay := &kusttypes.Kustomization{
+		TypeMeta: kusttypes.TypeMeta{
+			APIVersion: kusttypes.KustomizationVersion,
+			Kind:       kusttypes.KustomizationKind,
+		},
+		Resources: []string{"../../base"},
+	}
+
+	switch o.InstallationMode {
+	case InstallationModeFlat:
+		manifests, err := generateManifests(base)
+		if err != nil {
+			return nil, fmt.Errorf("failed generating manifests for '%s': %w", o.AppName, err)
+		}
+
+		app.manifests = manifests
+		base.Resources[0] = "install.yaml"
+	case InstallationModeNormal, "":
+	default:
+		return nil, fmt.Errorf("unknown installation mode: %s", o.InstallationMode)
+	}
+
+	if o.DestNamespace != "" {
+		app.namespace = kube.GenerateNamespace(o.DestNamespace)
+		overlay.Resources = append(overlay.Resources, "namespace.yaml")
+	}
+
+	app.base = base
+	app.overlay = overlay
+	app.functions = o.Functions
+	app.config = &Config{
+		AppName:           o.AppName,
+		UserGivenName:     o.AppName,
+		DestNamespace:     o.DestNamespace,
+		SrcPath:           filepath.Join(store.Default.AppsDir, o.AppName, store.Default.OverlaysDir, projectName),
+		SrcRepoURL:        srcRepoURL,
+		SrcTargetRevision: srcTargetRevision,
+	}
+
+	return app, nil
+}
+
+func (app *kustApp) CreateFiles(repofs fs.FS, projectName string) error {
+	basePath := repofs.Join(store.Default.AppsDir, app.opts.AppName, store.Default.BaseDir)
+	baseKustPath := repofs.Join(basePath, "kustomization.yaml")
+
+	if repofs.ExistsOrDie(baseKustPath) {
+		existingBase := &kusttypes.Kustomization{}
+		if err := repofs.ReadYamls(baseKustPath, existingBase); err != nil {
+			return fmt.Errorf("failed to read existing base for '%s': %w", app.opts.AppName, err)
+		}
+
+		if app.base != nil && !reflect.DeepEqual(existingBase.Resources, app.base.Resources) {
+			return fmt.Errorf("%w: '%s'", ErrAppCollisionWithExistingBase, app.opts.AppName)
+		}
+	} else {
+		base := app.base
+		if base == nil {
+			base = &kusttypes.Kustomization{
+				TypeMeta: kusttypes.TypeMeta{
+					APIVersion: kusttypes.KustomizationVersion,
+					Kind:       kusttypes.KustomizationKind,
+				},
+			}
+		}
+
+		if err := repofs.WriteYamls(baseKustPath, base); err != nil {
+			return fmt.Errorf("failed to write base kustomization for '%s': %w", app.opts.AppName, err)
+		}
+	}
+
+	overlayPath := repofs.Join(store.Default.AppsDir, app.opts.AppName, store.Default.OverlaysDir, projectName)
+	overlayKustPath := repofs.Join(overlayPath, "kustomization.yaml")
+	if repofs.ExistsOrDie(overlayKustPath) {
+		return fmt.Errorf("%w: '%s'", ErrAppAlreadyInstalledOnProject, app.opts.AppName)
+	}
+
+	functions := app.functions
+	if repofs.ExistsOrDie(basePath) {
+		discovered, err := discoverFunctionsFromAnnotations(repofs, basePath)
+		if err != nil {
+			return fmt.Errorf("failed to discover KRM functions for '%s': %w", app.opts.AppName, err)
+		}
+
+		functions = append(functions, discovered...)
+	}
+
+	overlay := app.overlay
+	if overlay == nil {
+		overlay = &kusttypes.Kustomization{
+			TypeMeta: kusttypes.TypeMeta{
+				APIVersion: kusttypes.KustomizationVersion,
+				Kind:       kusttypes.KustomizationKind,
+			},
+			Resources: []string{"../../base"},
+		}
+
+		if app.namespace != nil {
+			overlay.Resources = append(overlay.Resources, "namespace.yaml")
+		}
+	}
+
+	if len(functions) > 0 {
+		rendered := app.manifests
+		if rendered == nil {
+			var err error
+			rendered, err = generateManifests(app.base)
+			if err != nil {
+				return fmt.Errorf("failed generating manifests for '%s': %w", app.opts.AppName, err)
+			}
+		}
+
+		piped, err := runFunctions(context.Background(), rendered, functions)
+		if err != nil {
+			return fmt.Errorf("failed running KRM function pipeline for '%s': %w", app.opts.AppName, err)
+		}
+
+		if app.manifests != nil {
+			app.manifests = piped
+		} else {
+			if _, err := writeFile(repofs, repofs.Join(overlayPath, "functions.yaml"), "functions.yaml", piped); err != nil {
+				return err
+			}
+
+			for i, r := range overlay.Resources {
+				if r == "../../base" {
+					overlay.Resources[i] = "functions.yaml"
+				}
+			}
+		}
+	}
+
+	if app.manifests != nil {
+		if _, err := writeFile(repofs, repofs.Join(basePath, "install.yaml"), "install.yaml", app.manifests); err != nil {
+			return err
+		}
+	}
+
+	if err := repofs.WriteYamls(overlayKustPath, overlay); err != nil {
+		return fmt.Errorf("failed to write overlay kustomization for '%s': %w", app.opts.AppName, err)
+	}
+
+	if app.namespace != nil {
+		if err := repofs.WriteYamls(repofs.Join(overlayPath, "namespace.yaml"), app.namespace); err != nil {
+			return fmt.Errorf("failed to write namespace for '%s': %w", app.opts.AppName, err)
+		}
+	}
+
+	config := app.config
+	if config == nil {
+		config = &Config{
+			AppName: app.opts.AppName,
+			SrcPath: overlayPath,
+		}
+	}
+
+	if len(functions) > 0 {
+		config.Functions = functions
+	}
+
+	configData, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal config for '%s': %w", app.opts.AppName, err)
+	}
+
+	if _, err := writeFile(repofs, repofs.Join(overlayPath, "config.json"), "config.json", configData); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+type dirApp struct {
+	baseApp
+
+	config *Config
+}
+
+func newDirApp(o *CreateOptions, projectName, srcRepoURL, srcTargetRevision string) *dirApp {
+	return &dirApp{
+		baseApp: baseApp{opts: o},
+		config: &Config{
+			AppName:           o.AppName,
+			UserGivenName:     o.AppName,
+			DestNamespace:     o.DestNamespace,
+			SrcPath:           o.AppSpecifier,
+			SrcRepoURL:        srcRepoURL,
+			SrcTargetRevision: srcTargetRevision,
+		},
+	}
+}
+
+func (app *dirApp) CreateFiles(repofs fs.FS, projectName string) error {
+	appPath := repofs.Join(store.Default.AppsDir, app.opts.AppName, projectName)
+	if repofs.ExistsOrDie(appPath) {
+		return fmt.Errorf("%w: '%s'", ErrAppAlreadyInstalledOnProject, app.opts.AppName)
+	}
+
+	configData, err := json.MarshalIndent(app.config, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal config for '%s': %w", app.opts.AppName, err)
+	}
+
+	_, err = writeFile(repofs, repofs.Join(appPath, "config.json"), "config.json", configData)
+	return err
+}
+
+// writeFile writes data at path, unless a file already exists there, in which case it is left
+// untouched. name is only used to make any returned error easier to read.
+func writeFile(repofs fs.FS, path, name string, data []byte) (bool, error) {
+	exists, err := repofs.CheckExistsOrWrite(path, data)
+	if err != nil {
+		fullpath := repofs.Join(repofs.Root(), path)
+		return false, fmt.Errorf("failed to create '%s' file at '%s': %w", name, fullpath, err)
+	}
+
+	return exists, nil
+}
+
+// InferAppType inspects repofs and returns the application type it most likely contains.
+func InferAppType(repofs fs.FS) string {
+	if repofs.ExistsOrDie("app.yaml") && repofs.ExistsOrDie("components/params.libsonnet") {
+		return AppTypeKsonnet
+	}
+
+	if repofs.ExistsOrDie("Chart.yaml") {
+		return AppTypeHelm
+	}
+
+	if repofs.ExistsOrDie("kustomization.yaml") || repofs.ExistsOrDie("kustomization.yml") || repofs.ExistsOrDie("Kustomization") {
+		return AppTypeKustomize
+	}
+
+	return AppTypeDirectory
+}
+
+// DeleteFromProject removes appName's files for projectName, inferring whether it is a
+// kustomize-style (overlays/<project>) or directory-style (<project>) application purely by
+// inspecting repofs, so it works without having to reconstruct the original Application.
+// For a directory-style application the whole apps/<appName> directory is removed, including
+// the files of any other project.
+func DeleteFromProject(repofs fs.FS, appName, projectName string) error {
+	appPath := repofs.Join(store.Default.AppsDir, appName)
+	overlaysPath := repofs.Join(appPath, store.Default.OverlaysDir)
+
+	if repofs.ExistsOrDie(overlaysPath) {
+		overlayPath := repofs.Join(overlaysPath, projectName)
+		if !repofs.ExistsOrDie(overlayPath) {
+			return nil
+		}
+
+		entries, err := repofs.ReadDir(overlaysPath)
+		if err != nil {
+			return fmt.Errorf("failed to read overlays for '%s': %w", appName, err)
+		}
+
+		if len(entries) == 1 {
+			return repofs.Remove(appPath)
+		}
+
+		return repofs.Remove(overlayPath)
+	}
+
+	projectPath := repofs.Join(appPath, projectName)
+	if !repofs.ExistsOrDie(projectPath) {
+		return nil
+	}
+
+	return repofs.Remove(appPath)
+}
+
+// generateManifestsImpl renders k through kustomize, in-memory, and returns the resulting YAML.
+func generateManifestsImpl(k *kusttypes.Kustomization) ([]byte, error) {
+	td, err := ioutil.TempDir("", "autopilot-kust-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temp dir: %w", err)
+	}
+
+	defer os.RemoveAll(td)
+
+	kyaml, err := yaml.Marshal(k)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal kustomization: %w", err)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(td, "kustomization.yaml"), kyaml, 0400); err != nil {
+		return nil, fmt.Errorf("failed to write kustomization.yaml: %w", err)
+	}
+
+	opts := krusty.MakeDefaultOptions()
+	kust := krusty.MakeKustomizer(opts)
+	resMap, err := kust.Run(filesys.MakeFsOnDisk(), td)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build kustomization: %w", err)
+	}
+
+	return resMap.AsYaml()
+}
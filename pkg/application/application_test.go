@@ -1,6 +1,7 @@
 package application
 
 import (
+	"context"
 	"fmt"
 	"io/ioutil"
 	"path/filepath"
@@ -296,6 +297,44 @@ func Test_kustCreateFiles(t *testing.T) {
 				assert.True(t, repofs.ExistsOrDie(repofs.Join(store.Default.AppsDir, "app", store.Default.OverlaysDir, "project", "namespace.yaml")), "overlay namespace should exist")
 			},
 		},
+		"Should keep namespace.yaml referenced when a KRM pipeline also runs": {
+			beforeFn: func() (*kustApp, fs.FS, string) {
+				orgRunFunction := runFunction
+				t.Cleanup(func() { runFunction = orgRunFunction })
+				runFunction = func(ctx context.Context, fn FunctionSpec, input []byte) ([]byte, error) {
+					return []byte(`apiVersion: config.kubernetes.io/v1
+kind: ResourceList
+items:
+- apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    name: foo
+`), nil
+				}
+
+				app := &kustApp{
+					baseApp: baseApp{
+						opts: &CreateOptions{
+							AppName: "app",
+						},
+					},
+					namespace: kube.GenerateNamespace("namespace"),
+					functions: []FunctionSpec{{Image: "gcr.io/example/set-labels"}},
+				}
+				return app, fs.Create(memfs.New()), "project"
+			},
+			assertFn: func(t *testing.T, repofs fs.FS, err error) {
+				assert.NoError(t, err)
+				overlayKustPath := repofs.Join(store.Default.AppsDir, "app", store.Default.OverlaysDir, "project", "kustomization.yaml")
+				assert.True(t, repofs.ExistsOrDie(overlayKustPath))
+
+				overlay := &kusttypes.Kustomization{}
+				assert.NoError(t, repofs.ReadYamls(overlayKustPath, overlay))
+				assert.Contains(t, overlay.Resources, "functions.yaml")
+				assert.Contains(t, overlay.Resources, "namespace.yaml", "namespace.yaml must stay referenced once functions.yaml replaces the base")
+				assert.True(t, repofs.ExistsOrDie(repofs.Join(store.Default.AppsDir, "app", store.Default.OverlaysDir, "project", "namespace.yaml")), "overlay namespace should exist")
+			},
+		},
 		"Should fail when base kustomization is different from kustRes": {
 			beforeFn: func() (*kustApp, fs.FS, string) {
 				app := &kustApp{
@@ -0,0 +1,195 @@
+package application
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/argoproj-labs/argocd-autopilot/pkg/fs"
+	"github.com/argoproj-labs/argocd-autopilot/pkg/store"
+
+	"sigs.k8s.io/yaml"
+)
+
+// helmApp is an Application backed by an external helm chart, rather than a path inside the
+// source repo. The chart itself is never vendored into the gitops repo - only the values used
+// to render it for a given project.
+type helmApp struct {
+	baseApp
+
+	chart      string
+	version    string
+	repoURL    string
+	values     string
+	fileValues []string
+	config     *Config
+}
+
+func newHelmApp(o *CreateOptions, projectName, srcRepoURL, srcTargetRevision string) (*helmApp, error) {
+	if o.AppName == "" {
+		return nil, ErrEmptyAppName
+	}
+
+	if projectName == "" {
+		return nil, ErrEmptyProjectName
+	}
+
+	if o.HelmChart == "" {
+		return nil, ErrEmptyHelmChart
+	}
+
+	values, err := mergeHelmSet(o.HelmValues, o.HelmSet)
+	if err != nil {
+		return nil, fmt.Errorf("failed to apply --helm-set for '%s': %w", o.AppName, err)
+	}
+
+	app := &helmApp{
+		baseApp:    baseApp{opts: o},
+		chart:      o.HelmChart,
+		version:    o.HelmVersion,
+		repoURL:    o.HelmRepo,
+		values:     values,
+		fileValues: o.HelmValuesFiles,
+	}
+
+	app.config = &Config{
+		AppName:           o.AppName,
+		UserGivenName:     o.AppName,
+		DestNamespace:     o.DestNamespace,
+		SrcRepoURL:        srcRepoURL,
+		SrcTargetRevision: srcTargetRevision,
+		SrcChart:          fmt.Sprintf("%s:%s", o.HelmChart, o.HelmVersion),
+	}
+
+	return app, nil
+}
+
+// mergeHelmSet applies the `--helm-set key=value` overrides on top of the inline `--helm-values`
+// YAML, the same way `helm template --set` overlays values passed via `-f`, and returns the
+// resulting values.yaml content. A key may be a dotted path (e.g. "image.tag") to set a nested
+// field; set values always win over what's already present.
+func mergeHelmSet(values string, sets []string) (string, error) {
+	if len(sets) == 0 {
+		return values, nil
+	}
+
+	parsed := map[string]interface{}{}
+	if values != "" {
+		if err := yaml.Unmarshal([]byte(values), &parsed); err != nil {
+			return "", fmt.Errorf("failed to parse helm values: %w", err)
+		}
+	}
+
+	for _, set := range sets {
+		parts := strings.SplitN(set, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return "", fmt.Errorf("invalid --helm-set '%s', expected 'key=value'", set)
+		}
+
+		setNestedValue(parsed, strings.Split(parts[0], "."), parts[1])
+	}
+
+	merged, err := yaml.Marshal(parsed)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal merged helm values: %w", err)
+	}
+
+	return string(merged), nil
+}
+
+// setNestedValue sets val at the dotted path described by keys inside m, creating intermediate
+// maps as needed.
+func setNestedValue(m map[string]interface{}, keys []string, val string) {
+	if len(keys) == 1 {
+		m[keys[0]] = val
+		return
+	}
+
+	next, ok := m[keys[0]].(map[string]interface{})
+	if !ok {
+		next = map[string]interface{}{}
+		m[keys[0]] = next
+	}
+
+	setNestedValue(next, keys[1:], val)
+}
+
+// chartStub is the minimal, human-readable file written to an app's base directory, recording
+// which upstream chart/version/repository the overlays in this app are rendering.
+type chartStub struct {
+	Chart      string `json:"chart"`
+	Version    string `json:"version,omitempty"`
+	Repository string `json:"repository,omitempty"`
+}
+
+func (app *helmApp) CreateFiles(repofs fs.FS, projectName string) error {
+	basePath := repofs.Join(store.Default.AppsDir, app.opts.AppName, store.Default.BaseDir)
+	stubPath := repofs.Join(basePath, "chart.json")
+
+	stub := &chartStub{
+		Chart:      app.chart,
+		Version:    app.version,
+		Repository: app.repoURL,
+	}
+
+	if repofs.ExistsOrDie(stubPath) {
+		existing := &chartStub{}
+		if err := repofs.ReadJson(stubPath, existing); err != nil {
+			return fmt.Errorf("failed to read existing chart stub for '%s': %w", app.opts.AppName, err)
+		}
+
+		if !reflect.DeepEqual(existing, stub) {
+			return fmt.Errorf("%w: '%s'", ErrAppCollisionWithExistingBase, app.opts.AppName)
+		}
+	} else {
+		stubData, err := json.MarshalIndent(stub, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal chart stub for '%s': %w", app.opts.AppName, err)
+		}
+
+		if _, err := writeFile(repofs, stubPath, "chart.json", stubData); err != nil {
+			return err
+		}
+	}
+
+	overlayPath := repofs.Join(store.Default.AppsDir, app.opts.AppName, store.Default.OverlaysDir, projectName)
+	valuesPath := repofs.Join(overlayPath, "values.yaml")
+	if repofs.ExistsOrDie(valuesPath) {
+		return fmt.Errorf("%w: '%s'", ErrAppAlreadyInstalledOnProject, app.opts.AppName)
+	}
+
+	if _, err := writeFile(repofs, valuesPath, "values.yaml", []byte(app.values)); err != nil {
+		return err
+	}
+
+	for _, fv := range app.fileValues {
+		data, err := ioutil.ReadFile(fv)
+		if err != nil {
+			return fmt.Errorf("failed to read helm values file '%s' for '%s': %w", fv, app.opts.AppName, err)
+		}
+
+		name := filepath.Base(fv)
+		if _, err := writeFile(repofs, repofs.Join(overlayPath, name), name, data); err != nil {
+			return err
+		}
+	}
+
+	config := app.config
+	if config == nil {
+		config = &Config{
+			AppName:  app.opts.AppName,
+			SrcChart: fmt.Sprintf("%s:%s", app.chart, app.version),
+		}
+	}
+
+	configData, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal config for '%s': %w", app.opts.AppName, err)
+	}
+
+	_, err = writeFile(repofs, repofs.Join(overlayPath, "config.json"), "config.json", configData)
+	return err
+}
@@ -0,0 +1,177 @@
+package application
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/argoproj-labs/argocd-autopilot/pkg/fs"
+	"github.com/argoproj-labs/argocd-autopilot/pkg/store"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_newHelmApp(t *testing.T) {
+	tests := map[string]struct {
+		opts        *CreateOptions
+		projectName string
+		wantErr     string
+	}{
+		"No app name": {
+			opts:    &CreateOptions{HelmChart: "redis"},
+			wantErr: ErrEmptyAppName.Error(),
+		},
+		"No project name": {
+			opts:    &CreateOptions{AppName: "name", HelmChart: "redis"},
+			wantErr: ErrEmptyProjectName.Error(),
+		},
+		"No helm chart": {
+			opts:        &CreateOptions{AppName: "name"},
+			projectName: "project",
+			wantErr:     ErrEmptyHelmChart.Error(),
+		},
+		"Valid": {
+			opts: &CreateOptions{
+				AppName:     "name",
+				HelmChart:   "redis",
+				HelmVersion: "1.2.3",
+				HelmRepo:    "https://charts.example.com",
+			},
+			projectName: "project",
+		},
+		"Invalid --helm-set": {
+			opts: &CreateOptions{
+				AppName:   "name",
+				HelmChart: "redis",
+				HelmSet:   []string{"badpair"},
+			},
+			projectName: "project",
+			wantErr:     "failed to apply --helm-set for 'name': invalid --helm-set 'badpair', expected 'key=value'",
+		},
+	}
+	for tname, tt := range tests {
+		t.Run(tname, func(t *testing.T) {
+			app, err := newHelmApp(tt.opts, tt.projectName, "github.com/owner/repo", "branch")
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, "redis", app.chart)
+			assert.Equal(t, "redis:1.2.3", app.config.SrcChart)
+		})
+	}
+}
+
+func Test_mergeHelmSet(t *testing.T) {
+	tests := map[string]struct {
+		values  string
+		sets    []string
+		want    string
+		wantErr string
+	}{
+		"No sets returns values unchanged": {
+			values: "foo: bar\n",
+			want:   "foo: bar\n",
+		},
+		"Sets a new top-level key": {
+			values: "foo: bar\n",
+			sets:   []string{"env=production"},
+			want:   "env: production\nfoo: bar\n",
+		},
+		"Sets a nested key, creating intermediate maps": {
+			sets: []string{"image.tag=v1.2.3"},
+			want: "image:\n  tag: v1.2.3\n",
+		},
+		"Set wins over an existing value": {
+			values: "image:\n  tag: v1.0.0\n",
+			sets:   []string{"image.tag=v1.2.3"},
+			want:   "image:\n  tag: v1.2.3\n",
+		},
+		"Invalid pair": {
+			sets:    []string{"noequals"},
+			wantErr: "invalid --helm-set 'noequals', expected 'key=value'",
+		},
+	}
+	for tname, tt := range tests {
+		t.Run(tname, func(t *testing.T) {
+			got, err := mergeHelmSet(tt.values, tt.sets)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_helmCreateFiles(t *testing.T) {
+	tests := map[string]struct {
+		beforeFn func() (*helmApp, fs.FS, string)
+		assertFn func(*testing.T, fs.FS, error)
+	}{
+		"Should create all files for a simple chart": {
+			beforeFn: func() (*helmApp, fs.FS, string) {
+				app := &helmApp{
+					baseApp: baseApp{opts: &CreateOptions{AppName: "app"}},
+					chart:   "redis",
+					version: "1.2.3",
+					repoURL: "https://charts.example.com",
+					values:  "foo: bar\n",
+				}
+				return app, fs.Create(memfs.New()), "project"
+			},
+			assertFn: func(t *testing.T, repofs fs.FS, err error) {
+				assert.NoError(t, err)
+				assert.True(t, repofs.ExistsOrDie(repofs.Join(store.Default.AppsDir, "app", store.Default.BaseDir, "chart.json")), "chart stub should exist")
+				assert.True(t, repofs.ExistsOrDie(repofs.Join(store.Default.AppsDir, "app", store.Default.OverlaysDir, "project", "values.yaml")), "values.yaml should exist")
+				assert.True(t, repofs.ExistsOrDie(repofs.Join(store.Default.AppsDir, "app", store.Default.OverlaysDir, "project", "config.json")), "config.json should exist")
+			},
+		},
+		"Should fail when chart stub collides": {
+			beforeFn: func() (*helmApp, fs.FS, string) {
+				app := &helmApp{
+					baseApp: baseApp{opts: &CreateOptions{AppName: "app"}},
+					chart:   "redis",
+					version: "1.2.3",
+					repoURL: "https://charts.example.com",
+				}
+				repofs := fs.Create(memfs.New())
+				stubData, _ := json.Marshal(&chartStub{
+					Chart:      "postgres",
+					Version:    "1.2.3",
+					Repository: "https://charts.example.com",
+				})
+				_, _ = writeFile(repofs, repofs.Join(store.Default.AppsDir, "app", store.Default.BaseDir, "chart.json"), "chart.json", stubData)
+				return app, repofs, "project"
+			},
+			assertFn: func(t *testing.T, _ fs.FS, err error) {
+				assert.ErrorIs(t, err, ErrAppCollisionWithExistingBase)
+			},
+		},
+		"Should fail when overlay already exists": {
+			beforeFn: func() (*helmApp, fs.FS, string) {
+				app := &helmApp{
+					baseApp: baseApp{opts: &CreateOptions{AppName: "app"}},
+					chart:   "redis",
+				}
+				repofs := fs.Create(memfs.New())
+				_ = repofs.WriteYamls(repofs.Join(store.Default.AppsDir, "app", store.Default.OverlaysDir, "project", "values.yaml"), map[string]string{"foo": "bar"})
+				return app, repofs, "project"
+			},
+			assertFn: func(t *testing.T, _ fs.FS, err error) {
+				assert.ErrorIs(t, err, ErrAppAlreadyInstalledOnProject)
+			},
+		},
+	}
+	for tname, tt := range tests {
+		t.Run(tname, func(t *testing.T) {
+			app, repofs, projectName := tt.beforeFn()
+			err := app.CreateFiles(repofs, projectName)
+			tt.assertFn(t, repofs, err)
+		})
+	}
+}
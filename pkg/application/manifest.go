@@ -0,0 +1,157 @@
+package application
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/argoproj-labs/argocd-autopilot/pkg/fs"
+
+	"sigs.k8s.io/yaml"
+)
+
+// manifestFile is the declarative format, read by CreateFromManifest, for creating many applications in a
+// single shot. It mirrors the grouped repository/chart layout platform teams already use to
+// describe a helm-based deployment: a list of repositories, each listing the charts (or
+// kustomize bases) to install from it.
+type manifestFile struct {
+	Repositories []manifestRepository `json:"repositories"`
+}
+
+type manifestRepository struct {
+	Repository string          `json:"repository"`
+	Charts     []manifestEntry `json:"charts"`
+}
+
+type manifestEntry struct {
+	Name       string   `json:"name"`
+	AppType    string   `json:"appType"`
+	Chart      string   `json:"chart"`
+	Version    string   `json:"version,omitempty"`
+	Opts       []string `json:"opts,omitempty"`
+	Values     string   `json:"values,omitempty"`
+	FileValues []string `json:"filevalues,omitempty"`
+}
+
+// resolveFileValues rewrites any relative FileValues path to be relative to the manifest file's
+// own directory, rather than the process's current working directory, so a manifest can be
+// invoked from anywhere and still find its sibling values files.
+func (e *manifestEntry) resolveFileValues(manifestDir string) {
+	for i, fv := range e.FileValues {
+		if !filepath.IsAbs(fv) {
+			e.FileValues[i] = filepath.Join(manifestDir, fv)
+		}
+	}
+}
+
+// manifestError aggregates the per-entry failures CreateFromManifest ran into, so that one bad
+// entry doesn't abort the whole batch.
+type manifestError struct {
+	errs []error
+}
+
+func (e *manifestError) Error() string {
+	msgs := make([]string, len(e.errs))
+	for i, err := range e.errs {
+		msgs[i] = err.Error()
+	}
+
+	return fmt.Sprintf("%d application(s) failed to be created:\n%s", len(e.errs), strings.Join(msgs, "\n"))
+}
+
+func (e *manifestError) Unwrap() []error {
+	return e.errs
+}
+
+// CreateFromManifest reads the declarative app-set config at manifestPath and creates every app
+// it describes under projectName, reusing the same collision rules as creating a single app
+// (ErrAppAlreadyInstalledOnProject, ErrAppCollisionWithExistingBase). Entries are processed in
+// the order they appear in the file and the resulting slice is sorted by app name, so that the
+// resulting commit is reviewable regardless of how the source file was authored. When failFast
+// is false, a failing entry is recorded and the rest of the batch still runs; when true,
+// CreateFromManifest returns on the first error, along with the apps that were already created
+// by the entries processed before it. This package defines no command-line flags; failFast is
+// set by the caller.
+func CreateFromManifest(repofs fs.FS, manifestPath, projectName string, failFast bool) ([]Application, error) {
+	data, err := ioutil.ReadFile(manifestPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest file '%s': %w", manifestPath, err)
+	}
+
+	manifest := &manifestFile{}
+	if err := yaml.Unmarshal(data, manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest file '%s': %w", manifestPath, err)
+	}
+
+	manifestDir := filepath.Dir(manifestPath)
+	apps := make([]Application, 0)
+	aggErr := &manifestError{}
+
+	for _, repo := range manifest.Repositories {
+		for _, entry := range repo.Charts {
+			entry.resolveFileValues(manifestDir)
+			app, err := newAppFromManifestEntry(entry, repo.Repository, projectName)
+			if err == nil {
+				err = app.CreateFiles(repofs, projectName)
+			}
+
+			if err != nil {
+				err = fmt.Errorf("%s: %w", entry.Name, err)
+				if failFast {
+					return apps, err
+				}
+
+				aggErr.errs = append(aggErr.errs, err)
+				continue
+			}
+
+			apps = append(apps, app)
+		}
+	}
+
+	sort.Slice(apps, func(i, j int) bool { return apps[i].Name() < apps[j].Name() })
+
+	if len(aggErr.errs) > 0 {
+		return apps, aggErr
+	}
+
+	return apps, nil
+}
+
+func newAppFromManifestEntry(entry manifestEntry, repoURL, projectName string) (Application, error) {
+	switch entry.AppType {
+	case AppTypeHelm:
+		opts := &CreateOptions{
+			AppName:         entry.Name,
+			AppType:         AppTypeHelm,
+			HelmChart:       entry.Chart,
+			HelmVersion:     entry.Version,
+			HelmRepo:        repoURL,
+			HelmValues:      entry.Values,
+			HelmValuesFiles: entry.FileValues,
+			HelmSet:         entry.Opts,
+		}
+
+		return newHelmApp(opts, projectName, repoURL, entry.Version)
+	case AppTypeKustomize, "":
+		opts := &CreateOptions{
+			AppName:      entry.Name,
+			AppType:      AppTypeKustomize,
+			AppSpecifier: entry.Chart,
+		}
+
+		return newKustApp(opts, projectName, repoURL, entry.Version)
+	case AppTypeDirectory:
+		opts := &CreateOptions{
+			AppName:      entry.Name,
+			AppType:      AppTypeDirectory,
+			AppSpecifier: entry.Chart,
+		}
+
+		return newDirApp(opts, projectName, repoURL, entry.Version), nil
+	default:
+		return nil, fmt.Errorf("unknown appType '%s' for entry '%s'", entry.AppType, entry.Name)
+	}
+}
@@ -0,0 +1,171 @@
+package application
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/argoproj-labs/argocd-autopilot/pkg/fs"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/stretchr/testify/assert"
+)
+
+const testManifest = `
+repositories:
+- repository: https://charts.example.com
+  charts:
+  - name: redis
+    appType: helm
+    chart: redis
+    version: 1.2.3
+    opts:
+    - image.tag=v1.2.3
+    values: |
+      foo: bar
+- repository: github.com/owner/repo
+  charts:
+  - name: guestbook
+    appType: kustomize
+    chart: github.com/owner/repo/guestbook
+`
+
+func writeManifest(t *testing.T, data string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "manifest.yaml")
+	if err := ioutil.WriteFile(path, []byte(data), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func Test_CreateFromManifest(t *testing.T) {
+	tests := map[string]struct {
+		manifest string
+		failFast bool
+		wantErr  string
+		assertFn func(*testing.T, []Application, error)
+	}{
+		"Should create every app in the manifest": {
+			manifest: testManifest,
+			assertFn: func(t *testing.T, apps []Application, err error) {
+				assert.NoError(t, err)
+				assert.Len(t, apps, 2)
+				assert.Equal(t, "guestbook", apps[0].Name())
+				assert.Equal(t, "redis", apps[1].Name())
+			},
+		},
+		"Should aggregate errors for bad entries by default": {
+			manifest: `
+repositories:
+- repository: https://charts.example.com
+  charts:
+  - name: bad
+    appType: helm
+  - name: redis
+    appType: helm
+    chart: redis
+`,
+			assertFn: func(t *testing.T, apps []Application, err error) {
+				assert.Len(t, apps, 1)
+				assert.EqualError(t, err, "1 application(s) failed to be created:\nbad: "+ErrEmptyHelmChart.Error())
+			},
+		},
+		"Should fail fast on the first bad entry when requested": {
+			manifest: `
+repositories:
+- repository: https://charts.example.com
+  charts:
+  - name: bad
+    appType: helm
+  - name: redis
+    appType: helm
+    chart: redis
+`,
+			failFast: true,
+			wantErr:  "bad: " + ErrEmptyHelmChart.Error(),
+		},
+		"Should reject an unknown appType": {
+			manifest: `
+repositories:
+- repository: https://charts.example.com
+  charts:
+  - name: bad
+    appType: unknown
+    chart: redis
+`,
+			assertFn: func(t *testing.T, apps []Application, err error) {
+				assert.Len(t, apps, 0)
+				assert.EqualError(t, err, "1 application(s) failed to be created:\nbad: unknown appType 'unknown' for entry 'bad'")
+			},
+		},
+	}
+	for tname, tt := range tests {
+		t.Run(tname, func(t *testing.T) {
+			manifestPath := writeManifest(t, tt.manifest)
+			repofs := fs.Create(memfs.New())
+
+			apps, err := CreateFromManifest(repofs, manifestPath, "project", tt.failFast)
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+
+			tt.assertFn(t, apps, err)
+		})
+	}
+}
+
+func Test_manifestEntry_resolveFileValues(t *testing.T) {
+	entry := manifestEntry{FileValues: []string{"values-prod.yaml", "/abs/values-stage.yaml"}}
+	entry.resolveFileValues("/repo/configs")
+
+	assert.Equal(t, []string{"/repo/configs/values-prod.yaml", "/abs/values-stage.yaml"}, entry.FileValues)
+}
+
+func Test_newAppFromManifestEntry(t *testing.T) {
+	tests := map[string]struct {
+		entry    manifestEntry
+		wantErr  string
+		assertFn func(*testing.T, Application)
+	}{
+		"Helm entry": {
+			entry: manifestEntry{Name: "redis", AppType: AppTypeHelm, Chart: "redis", Version: "1.2.3"},
+		},
+		"Helm entry with opts": {
+			entry: manifestEntry{Name: "redis", AppType: AppTypeHelm, Chart: "redis", Version: "1.2.3", Opts: []string{"image.tag=v1.2.3"}},
+			assertFn: func(t *testing.T, app Application) {
+				assert.Equal(t, "image:\n  tag: v1.2.3\n", app.(*helmApp).values)
+			},
+		},
+		"Kustomize entry": {
+			entry: manifestEntry{Name: "guestbook", AppType: AppTypeKustomize, Chart: "github.com/owner/repo/guestbook"},
+		},
+		"Defaults to kustomize": {
+			entry: manifestEntry{Name: "guestbook", Chart: "github.com/owner/repo/guestbook"},
+		},
+		"Directory entry": {
+			entry: manifestEntry{Name: "plain", AppType: AppTypeDirectory, Chart: "github.com/owner/repo/manifests"},
+		},
+		"Unknown appType": {
+			entry:   manifestEntry{Name: "bad", AppType: "unknown", Chart: "redis"},
+			wantErr: "unknown appType 'unknown' for entry 'bad'",
+		},
+	}
+	for tname, tt := range tests {
+		t.Run(tname, func(t *testing.T) {
+			app, err := newAppFromManifestEntry(tt.entry, "https://charts.example.com", "project")
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.entry.Name, app.Name())
+			if tt.assertFn != nil {
+				tt.assertFn(t, app)
+			}
+		})
+	}
+}
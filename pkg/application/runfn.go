@@ -0,0 +1,210 @@
+package application
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/argoproj-labs/argocd-autopilot/pkg/fs"
+
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// krmFunctionAnnotation, when present on a YAML resource inside an app's base directory,
+	// declares that resource's container image as a KRM function to run over the app's
+	// rendered manifests, in addition to whatever is set on CreateOptions.Functions.
+	krmFunctionAnnotation = "config.kubernetes.io/function"
+
+	defaultFunctionTimeout = 1 * time.Minute
+)
+
+// FunctionSpec describes a single KRM function to run over an app's rendered manifests.
+type FunctionSpec struct {
+	// Image is the container image implementing the function.
+	Image string `json:"image"`
+
+	// FunctionConfig is passed to the function as the functionConfig of its ResourceList,
+	// as opaque, inline YAML.
+	FunctionConfig string `json:"functionConfig,omitempty"`
+
+	// Mounts are extra `docker run -v` style bind-mounts the function needs.
+	Mounts []string `json:"mounts,omitempty"`
+
+	// Timeout bounds how long the function is allowed to run before it is killed.
+	Timeout time.Duration `json:"timeout,omitempty"`
+}
+
+type resourceList struct {
+	APIVersion     string                   `json:"apiVersion"`
+	Kind           string                   `json:"kind"`
+	Items          []map[string]interface{} `json:"items"`
+	FunctionConfig interface{}              `json:"functionConfig,omitempty"`
+}
+
+// runFunctions pipes manifests through each of fns in order, feeding each function's stdout as
+// the next function's input, and returns the final manifests.
+func runFunctions(ctx context.Context, manifests []byte, fns []FunctionSpec) ([]byte, error) {
+	items, err := splitManifests(manifests)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse manifests for KRM pipeline: %w", err)
+	}
+
+	for _, fn := range fns {
+		var functionConfig interface{}
+		if fn.FunctionConfig != "" {
+			if err := yaml.Unmarshal([]byte(fn.FunctionConfig), &functionConfig); err != nil {
+				return nil, fmt.Errorf("failed to parse functionConfig for '%s': %w", fn.Image, err)
+			}
+		}
+
+		input, err := yaml.Marshal(&resourceList{
+			APIVersion:     "config.kubernetes.io/v1",
+			Kind:           "ResourceList",
+			Items:          items,
+			FunctionConfig: functionConfig,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal ResourceList for '%s': %w", fn.Image, err)
+		}
+
+		timeout := fn.Timeout
+		if timeout == 0 {
+			timeout = defaultFunctionTimeout
+		}
+
+		fnCtx, cancel := context.WithTimeout(ctx, timeout)
+		output, err := runFunction(fnCtx, fn, input)
+		cancel()
+
+		if fnCtx.Err() == context.DeadlineExceeded {
+			return nil, fmt.Errorf("function %s timed out after %s", fn.Image, timeout)
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("function %s failed: %w", fn.Image, err)
+		}
+
+		out := &resourceList{}
+		if err := yaml.Unmarshal(output, out); err != nil {
+			return nil, fmt.Errorf("function %s returned malformed output: %w", fn.Image, err)
+		}
+
+		items = out.Items
+	}
+
+	return joinManifests(items)
+}
+
+// runFunction is a seam so tests can replace the actual `docker run` invocation.
+var runFunction = func(ctx context.Context, fn FunctionSpec, input []byte) ([]byte, error) {
+	args := []string{"run", "--rm", "-i"}
+	for _, m := range fn.Mounts {
+		args = append(args, "-v", m)
+	}
+	args = append(args, fn.Image)
+
+	cmd := exec.CommandContext(ctx, "docker", args...)
+	cmd.Stdin = bytes.NewReader(input)
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return nil, ctx.Err()
+		}
+
+		return nil, fmt.Errorf("%s: %w", strings.TrimSpace(stderr.String()), err)
+	}
+
+	return stdout.Bytes(), nil
+}
+
+func splitManifests(manifests []byte) ([]map[string]interface{}, error) {
+	items := []map[string]interface{}{}
+	docs := bytes.Split(manifests, []byte("\n---\n"))
+	for _, doc := range docs {
+		if len(bytes.TrimSpace(doc)) == 0 {
+			continue
+		}
+
+		item := map[string]interface{}{}
+		if err := yaml.Unmarshal(doc, &item); err != nil {
+			return nil, err
+		}
+
+		items = append(items, item)
+	}
+
+	return items, nil
+}
+
+func joinManifests(items []map[string]interface{}) ([]byte, error) {
+	docs := make([][]byte, 0, len(items))
+	for _, item := range items {
+		doc, err := yaml.Marshal(item)
+		if err != nil {
+			return nil, err
+		}
+
+		docs = append(docs, doc)
+	}
+
+	return bytes.Join(docs, []byte("---\n")), nil
+}
+
+// discoverFunctionsFromAnnotations scans the YAML files directly inside basePath for the
+// config.kubernetes.io/function annotation, and returns the FunctionSpecs it declares, in the
+// order their files were found. This lets a base directory declare its own KRM pipeline without
+// the app creator having to set CreateOptions.Functions.
+func discoverFunctionsFromAnnotations(repofs fs.FS, basePath string) ([]FunctionSpec, error) {
+	entries, err := repofs.ReadDir(basePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read base dir '%s': %w", basePath, err)
+	}
+
+	fns := []FunctionSpec{}
+	for _, entry := range entries {
+		if entry.IsDir() || !isYAMLFile(entry.Name()) {
+			continue
+		}
+
+		data, err := repofs.ReadFile(repofs.Join(basePath, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read '%s': %w", entry.Name(), err)
+		}
+
+		doc := map[string]interface{}{}
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			continue
+		}
+
+		metadata, ok := doc["metadata"].(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		annotations, ok := metadata["annotations"].(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		image, ok := annotations[krmFunctionAnnotation].(string)
+		if !ok || image == "" {
+			continue
+		}
+
+		fns = append(fns, FunctionSpec{Image: image})
+	}
+
+	return fns, nil
+}
+
+func isYAMLFile(name string) bool {
+	return strings.HasSuffix(name, ".yaml") || strings.HasSuffix(name, ".yml")
+}
@@ -0,0 +1,80 @@
+package application
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_runFunctions(t *testing.T) {
+	orgRunFunction := runFunction
+	defer func() { runFunction = orgRunFunction }()
+
+	manifests := []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: foo\n")
+
+	tests := map[string]struct {
+		fns         []FunctionSpec
+		runFunction func(ctx context.Context, fn FunctionSpec, input []byte) ([]byte, error)
+		wantErr     string
+		assertFn    func(*testing.T, []byte)
+	}{
+		"Success": {
+			fns: []FunctionSpec{{Image: "gcr.io/example/set-labels"}},
+			runFunction: func(ctx context.Context, fn FunctionSpec, input []byte) ([]byte, error) {
+				return []byte(`apiVersion: config.kubernetes.io/v1
+kind: ResourceList
+items:
+- apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    name: foo
+    labels:
+      set-by: gcr.io/example/set-labels
+`), nil
+			},
+			assertFn: func(t *testing.T, out []byte) {
+				assert.Contains(t, string(out), "set-by: gcr.io/example/set-labels")
+			},
+		},
+		"Non-zero exit": {
+			fns: []FunctionSpec{{Image: "gcr.io/example/broken"}},
+			runFunction: func(ctx context.Context, fn FunctionSpec, input []byte) ([]byte, error) {
+				return nil, fmt.Errorf("exit status 1")
+			},
+			wantErr: "function gcr.io/example/broken failed: exit status 1",
+		},
+		"Timeout": {
+			fns: []FunctionSpec{{Image: "gcr.io/example/slow", Timeout: time.Millisecond}},
+			runFunction: func(ctx context.Context, fn FunctionSpec, input []byte) ([]byte, error) {
+				<-ctx.Done()
+				return nil, ctx.Err()
+			},
+			wantErr: "function gcr.io/example/slow timed out after 1ms",
+		},
+		"Malformed output": {
+			fns: []FunctionSpec{{Image: "gcr.io/example/broken-output"}},
+			runFunction: func(ctx context.Context, fn FunctionSpec, input []byte) ([]byte, error) {
+				return []byte("not: [valid"), nil
+			},
+			wantErr: "function gcr.io/example/broken-output returned malformed output",
+		},
+	}
+
+	for tname, tt := range tests {
+		t.Run(tname, func(t *testing.T) {
+			runFunction = tt.runFunction
+			out, err := runFunctions(context.Background(), manifests, tt.fns)
+			if tt.wantErr != "" {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), tt.wantErr)
+				return
+			}
+
+			assert.NoError(t, err)
+			tt.assertFn(t, out)
+		})
+	}
+}